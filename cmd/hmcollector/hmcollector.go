@@ -22,7 +22,9 @@ import (
 	"go.uber.org/zap"
 
 	"stash.us.cray.com/HMS/hms-hmcollector/internal/hmcollector"
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/metrics"
 	"stash.us.cray.com/HMS/hms-hmcollector/internal/river_collector"
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/workerpool"
 	rf "stash.us.cray.com/HMS/hms-smd/pkg/redfish"
 	"stash.us.cray.com/HMS/hms-certs/pkg/hms_certs"
 )
@@ -56,9 +58,19 @@ var (
 	hsmRefreshInterval = flag.Int("hsm_refresh_interval", 30,
 		"The interval to check HSM for new Redfish Endpoints in seconds.")
 
+	pollRetryTimeout = flag.Int("poll_retry_timeout", 0,
+		"Cumulative seconds to keep retrying a failing endpoint poll before marking it unhealthy and entering cooldown. 0 disables retry-until-deadline.")
+	pollRetrySleep = flag.Int("poll_retry_sleep", 5,
+		"Seconds to sleep between retries of a failing endpoint poll.")
+	pollCooldown = flag.Int("poll_cooldown", 60,
+		"Seconds an endpoint marked unhealthy is skipped before it's eligible to be polled again.")
+
 	smURL    = flag.String("sm_url", "", "Address of the State Manager.")
 	restURL  = flag.String("rest_url", "", "Address for Redfish events to target.")
 	restPort = flag.Int("rest_port", 80, "The port the REST interface listens on.")
+
+	adminPort       = flag.Int("admin_port", 8081, "The port the admin interface (metrics/pprof) listens on.")
+	enableProfiling = flag.Bool("enable_profiling", false, "Should the /debug/pprof/* handlers be exposed on the admin port?")
 	caURI    = flag.String("hmcollector_ca_uri","","URI of the CA cert bundle.")
 	logInsecFailover = flag.Bool("hmcollector_log_insecure_failover",true,"Log/don't log TLS insecure failovers.")
 	httpTimeout = flag.Int("http_timeout",10,"Timeout in seconds for HTTP operations.")
@@ -68,11 +80,17 @@ var (
 	IgnoreProvidedTimestamp = flag.Bool("ignore_provided_timestamp", false,
 		"Should the collector disregard any provided timestamps and instead use a local value of NOW?")
 
-	kafkaBrokers []*hmcollector.KafkaBroker
+	// kafkaBrokers is replaced wholesale by setupKafka at startup and by
+	// kafkaCAChangeCB on a background CA-roll watcher goroutine while
+	// produceToKafka ranges over it concurrently from worker-pool jobs and
+	// REST requests, so reads/writes go through kafkaBrokersLock.
+	kafkaBrokers     []*hmcollector.KafkaBroker
+	kafkaBrokersLock sync.RWMutex
 
 	Running = true
 
 	RestSRV   *http.Server = nil
+	AdminSRV  *http.Server = nil
 	WaitGroup sync.WaitGroup
 
 	ctx context.Context
@@ -89,6 +107,16 @@ var (
 
 	hsmEndpointRefreshShutdown chan bool
 	HSMEndpoints               map[string]*rf.RedfishEPDescription
+
+	gigabyteCollector river_collector.GigabyteRiverCollector
+	intelCollector    river_collector.IntelRiverCollector
+	hpeCollector      river_collector.HPERiverCollector
+
+	// Pool bounds the fan-out across Redfish polling and subscription
+	// processing so that a flood of endpoints
+	// can't spawn an unbounded number of goroutines, and so a panic in a
+	// single job (e.g. a malformed Redfish payload) can't crash the process.
+	Pool *workerpool.Pool
 )
 
 type EndpointWithCollector struct {
@@ -96,6 +124,141 @@ type EndpointWithCollector struct {
 	RiverCollector river_collector.RiverCollector
 	LastContacted  *time.Time
 	Model          string
+
+	// CooldownUntil is set once an endpoint exhausts poll_retry_timeout; the
+	// polling loop should skip the endpoint until this time has passed. Nil
+	// means the endpoint isn't in cooldown.
+	CooldownUntil *time.Time
+}
+
+// endpointInCooldown reports whether endpoint was recently marked unhealthy
+// and is still within its poll_cooldown window.
+func endpointInCooldown(endpoint *EndpointWithCollector) bool {
+	return endpoint.CooldownUntil != nil && time.Now().Before(*endpoint.CooldownUntil)
+}
+
+// pollRetryInFlight tracks which (endpoint, telemetryType) polls currently
+// have a retry pending, so doPolling doesn't submit a duplicate job for one
+// on every tick while it's mid-retry.
+var (
+	pollRetryInFlight   = map[string]bool{}
+	pollRetryInFlightMu sync.Mutex
+)
+
+func pollRetryKey(endpointID string, telemetryType river_collector.TelemetryType) string {
+	return endpointID + "|" + string(telemetryType)
+}
+
+// endpointPollRetrying reports whether endpoint/telemetryType has a retry
+// currently pending.
+func endpointPollRetrying(endpointID string, telemetryType river_collector.TelemetryType) bool {
+	pollRetryInFlightMu.Lock()
+	defer pollRetryInFlightMu.Unlock()
+	return pollRetryInFlight[pollRetryKey(endpointID, telemetryType)]
+}
+
+func setPollRetrying(endpointID string, telemetryType river_collector.TelemetryType, retrying bool) {
+	pollRetryInFlightMu.Lock()
+	defer pollRetryInFlightMu.Unlock()
+
+	key := pollRetryKey(endpointID, telemetryType)
+	if retrying {
+		pollRetryInFlight[key] = true
+	} else {
+		delete(pollRetryInFlight, key)
+	}
+}
+
+// pollEndpointWithRetry runs attempt once and, on failure, requeues it onto
+// Pool after poll_retry_sleep instead of blocking the worker that ran it, so
+// a flapping endpoint only ever occupies a worker for the duration of a
+// single HTTP call. Retries continue until attempt succeeds or the
+// cumulative elapsed time since the first attempt exceeds poll_retry_timeout
+// (a timeout of 0 disables retrying: the first failure ends the poll without
+// marking the endpoint unhealthy). An endpoint that exhausts its retry
+// deadline is marked unhealthy via CooldownUntil and skipped for
+// poll_cooldown seconds. Poll latency is observed once per chain, as the time
+// from the first attempt to the step that ends it, and LastContacted is only
+// updated when an attempt succeeds. Regardless of outcome, endpointPollRetrying(endpoint, telemetryType) stops
+// reporting this poll as in-flight once this function's terminal step runs.
+func pollEndpointWithRetry(endpoint *EndpointWithCollector, telemetryType river_collector.TelemetryType,
+	attempt func() error) {
+	pollEndpointAttempt(endpoint, telemetryType, attempt, time.Now(), 0)
+}
+
+func pollEndpointAttempt(endpoint *EndpointWithCollector, telemetryType river_collector.TelemetryType,
+	attempt func() error, start time.Time, attempts int) {
+	attempts++
+
+	err := attempt()
+	elapsed := time.Since(start)
+
+	if err == nil {
+		metrics.PollAttemptsTotal.WithLabelValues(endpoint.Endpoint.ID, string(telemetryType), "success").Inc()
+		metrics.RedfishPollLatencySeconds.WithLabelValues(endpoint.Endpoint.ID, string(telemetryType)).
+			Observe(elapsed.Seconds())
+
+		now := time.Now()
+		endpoint.LastContacted = &now
+
+		setPollRetrying(endpoint.Endpoint.ID, telemetryType, false)
+		return
+	}
+
+	metrics.PollAttemptsTotal.WithLabelValues(endpoint.Endpoint.ID, string(telemetryType), "failure").Inc()
+
+	if *pollRetryTimeout <= 0 {
+		// Retry-until-deadline is disabled: fail fast on the first
+		// error, same as before this feature existed. In particular,
+		// this must NOT mark the endpoint unhealthy/cooldown, since
+		// that's new behavior that should only kick in when an operator
+		// opts into poll_retry_timeout.
+		logger.Debug("Poll failed; retry-until-deadline disabled, not retrying.",
+			zap.String("endpointID", endpoint.Endpoint.ID),
+			zap.Error(err))
+
+		metrics.RedfishPollLatencySeconds.WithLabelValues(endpoint.Endpoint.ID, string(telemetryType)).
+			Observe(elapsed.Seconds())
+
+		setPollRetrying(endpoint.Endpoint.ID, telemetryType, false)
+		return
+	}
+
+	if elapsed >= time.Duration(*pollRetryTimeout)*time.Second {
+		cooldownUntil := time.Now().Add(time.Duration(*pollCooldown) * time.Second)
+		endpoint.CooldownUntil = &cooldownUntil
+		metrics.PollCooldownTotal.WithLabelValues(endpoint.Endpoint.ID).Inc()
+
+		logger.Warn("Marking endpoint unhealthy after exhausting poll retries.",
+			zap.String("endpointID", endpoint.Endpoint.ID),
+			zap.Int("attempts", attempts),
+			zap.Duration("elapsed", elapsed),
+			zap.Time("cooldownUntil", cooldownUntil),
+			zap.Error(err))
+
+		metrics.RedfishPollLatencySeconds.WithLabelValues(endpoint.Endpoint.ID, string(telemetryType)).
+			Observe(elapsed.Seconds())
+
+		setPollRetrying(endpoint.Endpoint.ID, telemetryType, false)
+		return
+	}
+
+	logger.Debug("Retrying endpoint poll after failure.",
+		zap.String("endpointID", endpoint.Endpoint.ID),
+		zap.Int("attempt", attempts),
+		zap.Duration("elapsed", elapsed),
+		zap.Error(err))
+
+	setPollRetrying(endpoint.Endpoint.ID, telemetryType, true)
+
+	time.AfterFunc(time.Duration(*pollRetrySleep)*time.Second, func() {
+		Pool.Submit(workerpool.Job{
+			Stage: "polling",
+			Run: func() {
+				pollEndpointAttempt(endpoint, telemetryType, attempt, start, attempts)
+			},
+		})
+	})
 }
 
 type jsonPayload struct {
@@ -146,6 +309,8 @@ func doUpdateHSMEndpoints() {
 			}
 		}
 
+		metrics.HSMEndpointsGauge.Set(float64(len(HSMEndpoints)))
+
 		// Use a channel in case we have long refresh intervals so we don't wait around for things to exit.
 		select {
 		case <-hsmEndpointRefreshShutdown:
@@ -252,6 +417,42 @@ func caChangeCB(caBundle string) {
 	}
 }
 
+// kafkaCAChangeCB rebuilds the Kafka producers whenever the CA trust bundle
+// rolls, draining each broker's in-flight messages with Flush first (the
+// same drain used during shutdown). Flush waits at most 15 seconds per
+// broker; any messages still undelivered after that are logged as abandoned.
+func kafkaCAChangeCB(caBundle string) {
+	logger.Info("CA bundle rolled; draining in-flight Kafka messages before rebuilding producers...")
+
+	kafkaBrokersLock.RLock()
+	brokers := kafkaBrokers
+	kafkaBrokersLock.RUnlock()
+
+	for idx := range brokers {
+		thisBroker := brokers[idx]
+
+		abandonedMessages := thisBroker.KafkaProducer.Flush(15 * 1000)
+		logger.Info("Flushed Kafka broker ahead of producer rebuild.",
+			zap.Any("broker", thisBroker),
+			zap.Int("abandonedMessages", abandonedMessages))
+	}
+
+	// Unlike the startup call to setupKafka, a failed rebuild here must not
+	// take down a collector that's already serving traffic over a transient
+	// Vault/Kafka hiccup during a routine cert roll - log it and keep the
+	// producers we already have.
+	rebuilt, err := loadKafkaBrokers()
+	if err != nil {
+		logger.Error("Unable to rebuild Kafka producers after CA bundle roll; keeping existing producers.",
+			zap.Error(err))
+		return
+	}
+
+	kafkaBrokersLock.Lock()
+	kafkaBrokers = rebuilt
+	kafkaBrokersLock.Unlock()
+}
+
 
 func main() {
 	setupLogging()
@@ -264,6 +465,13 @@ func main() {
 	var cancel context.CancelFunc
 	ctx, cancel = context.WithCancel(context.Background())
 
+	// The pool's own lifecycle is intentionally independent of ctx, which
+	// only cancels in-flight HTTP requests: it is stopped solely via
+	// Pool.Stop below, after the polling/RF-subscribe producer loops have
+	// been signalled to stop submitting new jobs.
+	Pool = workerpool.New(logger, NumWorkers, NumWorkers*4)
+	Pool.Start(NumWorkers)
+
 	// For performance reasons we'll keep the client that was created for this base request and reuse it later.
 	httpClient = retryablehttp.NewClient()
 	transport := &http.Transport{
@@ -310,6 +518,12 @@ func main() {
                 zap.String("URI",*caURI),zap.Error(err))
             logger.Warn("   This means no updates when CA bundle is rolled.")
         }
+
+		if kafkaErr := hms_certs.CAUpdateRegister(*caURI, kafkaCAChangeCB); kafkaErr != nil {
+			logger.Warn("Unable to register CA bundle watcher for Kafka producers.",
+				zap.String("URI", *caURI), zap.Error(kafkaErr))
+			logger.Warn("   This means no Kafka producer rebuild when CA bundle is rolled.")
+		}
     } else {
        logger.Warn("No CA bundle URI specified, not watching for CA changes.")
     }
@@ -319,10 +533,19 @@ func main() {
 	httpClient.Logger = httpLogger
 
 	if *restEnabled {
+		if err := loadRestSignatureSecret(); err != nil {
+			logger.Panic("Unable to load REST signature secret.", zap.Error(err))
+		}
+
 		// Only enable handling of the root URL if REST is "enabled".
-		http.HandleFunc("/", parseRequest)
+		http.HandleFunc("/", requireValidSignature(parseRequest))
 
-		logger.Info("REST collection endpoint enabled.")
+		if *restRequireSignature {
+			logger.Info("REST collection endpoint enabled with signature enforcement.",
+				zap.Int("restSignatureWindow", *restSignatureWindow))
+		} else {
+			logger.Info("REST collection endpoint enabled.")
+		}
 	}
 
 	// Because we need our liveness/readiness probes to always work, we always setup a HTTP server.
@@ -331,6 +554,11 @@ func main() {
 	logger.Info("Starting rest server.")
 	doRest()
 
+	// The admin server carries /metrics and, optionally, /debug/pprof/* so
+	// operators can scrape the collector's own health instead of inferring
+	// it from logs.
+	doAdmin()
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	hsmEndpointRefreshShutdown = make(chan bool)
@@ -355,11 +583,24 @@ func main() {
 			PollingShutdown <- true
 		}
 
+		// http.Server.Shutdown panics on a nil context as soon as it needs to
+		// wait on ctx.Done() (i.e. whenever a server has an active
+		// connection, such as an in-progress Prometheus scrape), so give it
+		// a bounded one like Pool.Stop uses below.
+		srvShutdownCtx, srvShutdownCancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer srvShutdownCancel()
+
 		if RestSRV != nil {
-			if err := RestSRV.Shutdown(nil); err != nil {
+			if err := RestSRV.Shutdown(srvShutdownCtx); err != nil {
 				logger.Panic("Unable to stop REST collection server!", zap.Error(err))
 			}
 		}
+
+		if AdminSRV != nil {
+			if err := AdminSRV.Shutdown(srvShutdownCtx); err != nil {
+				logger.Panic("Unable to stop admin server!", zap.Error(err))
+			}
+		}
 	}()
 
 	HSMEndpoints = make(map[string]*rf.RedfishEPDescription)
@@ -409,10 +650,20 @@ func main() {
 	// We'll spend pretty much the rest of life blocking on the next line.
 	WaitGroup.Wait()
 
+	// Give in-flight jobs a bounded window to drain instead of blocking
+	// shutdown forever on a stuck one.
+	poolShutdownCtx, poolShutdownCancel := context.WithTimeout(context.Background(), 15*time.Second)
+	Pool.Stop(poolShutdownCtx)
+	poolShutdownCancel()
+
 	// Close the connection to Kafka to make sure any buffered data gets flushed.
 	defer func() {
-		for idx := range kafkaBrokers {
-			thisBroker := kafkaBrokers[idx]
+		kafkaBrokersLock.RLock()
+		brokers := kafkaBrokers
+		kafkaBrokersLock.RUnlock()
+
+		for idx := range brokers {
+			thisBroker := brokers[idx]
 
 			// This call to Flush is given a maximum timeout of 15 seconds (which is entirely arbitrary and should
 			// never take that long). It's very likely this will return almost immediately in most cases.
@@ -0,0 +1,108 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"go.uber.org/zap"
+
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/hmcollector"
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/metrics"
+)
+
+// loadKafkaBrokers reads kafkaBrokersConfigFile, resolves each broker's
+// security material (Vault-backed or file-based), and builds a Kafka
+// producer per broker. It returns an error rather than panicking so that a
+// caller rebuilding producers after a CA bundle roll can fall back to the
+// existing producers instead of taking the whole collector down.
+func loadKafkaBrokers() ([]*hmcollector.KafkaBroker, error) {
+	configBytes, err := ioutil.ReadFile(*kafkaBrokersConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Kafka brokers config file %s: %v",
+			*kafkaBrokersConfigFile, err)
+	}
+
+	var brokers []*hmcollector.KafkaBroker
+	if err := json.Unmarshal(configBytes, &brokers); err != nil {
+		return nil, fmt.Errorf("unable to parse Kafka brokers config file: %v", err)
+	}
+
+	for _, broker := range brokers {
+		if err := broker.Security.ResolveFromVault(); err != nil {
+			return nil, fmt.Errorf("unable to resolve Kafka broker %s security material from Vault: %v",
+				broker.Name, err)
+		}
+
+		configMap := &kafka.ConfigMap{
+			"bootstrap.servers": strings.Join(broker.Peers, ","),
+		}
+
+		if err := broker.Security.ApplyToConfigMap(configMap); err != nil {
+			return nil, fmt.Errorf("unable to apply Kafka broker %s security configuration: %v",
+				broker.Name, err)
+		}
+
+		producer, err := kafka.NewProducer(configMap)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create Kafka producer for broker %s: %v", broker.Name, err)
+		}
+
+		broker.KafkaProducer = producer
+
+		securityProtocol := string(hmcollector.SecurityProtocolPlaintext)
+		if broker.Security != nil && broker.Security.SecurityProtocol != "" {
+			securityProtocol = string(broker.Security.SecurityProtocol)
+		}
+
+		logger.Info("Configured Kafka broker.",
+			zap.String("broker", broker.Name),
+			zap.String("securityProtocol", securityProtocol))
+	}
+
+	return brokers, nil
+}
+
+// setupKafka loads the Kafka broker configuration and replaces kafkaBrokers
+// wholesale, panicking on failure. It's meant for startup, where there are
+// no existing producers to fall back to; kafkaCAChangeCB calls
+// loadKafkaBrokers directly so a rebuild failure well after startup doesn't
+// crash an already-serving collector.
+func setupKafka() {
+	brokers, err := loadKafkaBrokers()
+	if err != nil {
+		logger.Panic("Unable to set up Kafka brokers.", zap.Error(err))
+	}
+
+	kafkaBrokersLock.Lock()
+	kafkaBrokers = brokers
+	kafkaBrokersLock.Unlock()
+}
+
+// produceToKafka hands payload to every configured Kafka broker's producer
+// under topic, recording the outcome on KafkaProduceTotal.
+func produceToKafka(topic string, payload []byte) {
+	kafkaBrokersLock.RLock()
+	brokers := kafkaBrokers
+	kafkaBrokersLock.RUnlock()
+
+	for _, broker := range brokers {
+		err := broker.KafkaProducer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          payload,
+		}, nil)
+
+		result := "success"
+		if err != nil {
+			result = "failure"
+			logger.Error("Unable to produce Kafka message.",
+				zap.String("broker", broker.Name), zap.String("topic", topic), zap.Error(err))
+		}
+
+		metrics.KafkaProduceTotal.WithLabelValues(broker.Name, topic, result).Inc()
+	}
+}
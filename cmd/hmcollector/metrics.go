@@ -0,0 +1,50 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// doAdmin starts the admin HTTP server that exposes the Prometheus /metrics
+// endpoint and, when enabled, the net/http/pprof handlers. It follows the
+// same fire-and-forget pattern as doRest: the server is started on its own
+// goroutine and AdminSRV.Shutdown is called from the signal handler in main.
+func doAdmin() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	if *enableProfiling {
+		// Mirrors the handlers net/http/pprof registers on DefaultServeMux,
+		// kept off the admin mux unless explicitly requested so production
+		// deployments don't expose profiling by default.
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	AdminSRV = &http.Server{
+		Addr:    fmt.Sprintf(":%d", *adminPort),
+		Handler: mux,
+	}
+
+	WaitGroup.Add(1)
+	go func() {
+		defer WaitGroup.Done()
+
+		logger.Info("Starting admin server.",
+			zap.Int("adminPort", *adminPort),
+			zap.Bool("profilingEnabled", *enableProfiling))
+
+		if err := AdminSRV.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("Admin server failed.", zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/hmcollector"
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/river_collector"
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/workerpool"
+)
+
+// doPolling fans a poll of every known endpoint, for every telemetry type,
+// out onto Pool: that bounds concurrency to NumWorkers and means a panic
+// parsing one endpoint's payload (e.g. IntelRiverCollector.ParseJSONPowerEvents
+// on a malformed Voltage.Name) can't take the rest of polling down with it.
+func doPolling() {
+	defer WaitGroup.Done()
+
+	for Running {
+		for _, endpoint := range HSMEndpoints {
+			if endpointInCooldown(endpoint) {
+				continue
+			}
+
+			endpoint := endpoint
+
+			for _, telemetryType := range telemetryTypes {
+				telemetryType := telemetryType
+
+				// A poll already mid-retry for this endpoint/telemetryType
+				// has its own timer driving re-dispatch; submitting another
+				// job for it here would let a class of failure wider than
+				// NumWorkers exhaust the whole pool and stall healthy
+				// endpoints too.
+				if endpointPollRetrying(endpoint.Endpoint.ID, telemetryType) {
+					continue
+				}
+
+				Pool.Submit(workerpool.Job{
+					Stage: "polling",
+					Run: func() {
+						pollEndpoint(endpoint, telemetryType)
+					},
+				})
+			}
+		}
+
+		select {
+		case <-PollingShutdown:
+			logger.Info("Polling routine shutdown.")
+			return
+		case <-time.After(time.Duration(*pollingInterval) * time.Second):
+			continue
+		}
+	}
+}
+
+// pollEndpoint polls a single endpoint for a single telemetry type, retrying
+// per poll_retry_timeout/poll_retry_sleep before giving up. Retries are
+// requeued onto Pool rather than awaited inline, so this call returns as
+// soon as the first attempt completes; see
+// pollEndpointWithRetry for how latency and LastContacted get recorded once
+// the whole retry chain concludes.
+func pollEndpoint(endpoint *EndpointWithCollector, telemetryType river_collector.TelemetryType) {
+	pollEndpointWithRetry(endpoint, telemetryType, func() error {
+		return fetchAndProduceTelemetry(endpoint, telemetryType)
+	})
+}
+
+// fetchAndProduceTelemetry fetches the payload for telemetryType from
+// endpoint and forwards the parsed events to Kafka.
+func fetchAndProduceTelemetry(endpoint *EndpointWithCollector, telemetryType river_collector.TelemetryType) error {
+	url := endpoint.RiverCollector.GetPayloadURLForTelemetryType(endpoint.Endpoint, telemetryType)
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var events []hmcollector.Event
+	switch telemetryType {
+	case river_collector.TelemetryTypePower:
+		events = endpoint.RiverCollector.ParseJSONPowerEvents(body, endpoint.Endpoint.ID)
+	case river_collector.TelemetryTypeThermal:
+		events = endpoint.RiverCollector.ParseJSONThermalEvents(body, endpoint.Endpoint.ID)
+	}
+
+	for _, event := range events {
+		eventBytes, marshalErr := json.Marshal(event)
+		if marshalErr != nil {
+			logger.Error("Unable to marshal polled event for Kafka.", zap.Error(marshalErr))
+			continue
+		}
+
+		produceToKafka("telemetry", eventBytes)
+	}
+
+	return nil
+}
+
+// doRFSubscribe fans a Redfish event subscription (re)create across every
+// known endpoint out onto Pool, for the same reason doPolling does.
+func doRFSubscribe() {
+	defer WaitGroup.Done()
+
+	for Running {
+		for _, endpoint := range HSMEndpoints {
+			endpoint := endpoint
+
+			Pool.Submit(workerpool.Job{
+				Stage: "rf_subscribe",
+				Run: func() {
+					subscribeEndpoint(endpoint)
+				},
+			})
+		}
+
+		select {
+		case <-RFSubscribeShutdown:
+			logger.Info("Redfish Event Subscribing routine shutdown.")
+			return
+		case <-time.After(time.Duration(*hsmRefreshInterval) * time.Second):
+			continue
+		}
+	}
+}
+
+// subscribeEndpoint creates or refreshes endpoint's Redfish event
+// subscription so it starts streaming telemetry to restURL.
+func subscribeEndpoint(endpoint *EndpointWithCollector) {
+	subscriptionURL := fmt.Sprintf("https://%s/redfish/v1/EventService/Subscriptions", endpoint.Endpoint.FQDN)
+
+	subscription := struct {
+		Destination string   `json:"Destination"`
+		EventTypes  []string `json:"EventTypes"`
+		Context     string   `json:"Context"`
+		Protocol    string   `json:"Protocol"`
+	}{
+		Destination: *restURL,
+		EventTypes:  []string{"Alert"},
+		Context:     endpoint.Endpoint.ID,
+		Protocol:    "Redfish",
+	}
+
+	body, err := json.Marshal(subscription)
+	if err != nil {
+		logger.Error("Unable to marshal Redfish subscription body.",
+			zap.String("endpointID", endpoint.Endpoint.ID), zap.Error(err))
+		return
+	}
+
+	resp, err := httpClient.Post(subscriptionURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Error("Unable to create Redfish event subscription.",
+			zap.String("endpointID", endpoint.Endpoint.ID), zap.Error(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		logger.Warn("Redfish event subscription request did not succeed.",
+			zap.String("endpointID", endpoint.Endpoint.ID), zap.Int("statusCode", resp.StatusCode))
+		return
+	}
+
+	now := time.Now()
+	endpoint.LastContacted = &now
+}
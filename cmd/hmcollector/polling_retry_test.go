@@ -0,0 +1,167 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package main
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/river_collector"
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/workerpool"
+	rf "stash.us.cray.com/HMS/hms-smd/pkg/redfish"
+)
+
+// TestMain gives the retry tests a real Pool to requeue retries onto, since
+// pollEndpointWithRetry now dispatches retries via Pool.Submit instead of
+// blocking inline, and a logger for the failure paths to log through.
+func TestMain(m *testing.M) {
+	logger = zap.NewNop()
+	Pool = workerpool.New(zap.NewNop(), 8, 32)
+	Pool.Start(8)
+	os.Exit(m.Run())
+}
+
+func newTestEndpoint(id string) *EndpointWithCollector {
+	return &EndpointWithCollector{
+		Endpoint: &rf.RedfishEPDescription{ID: id},
+	}
+}
+
+func waitForCondition(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for condition")
+}
+
+func TestPollEndpointWithRetryDisabledFailsFastWithoutCooldown(t *testing.T) {
+	*pollRetryTimeout = 0
+	*pollRetrySleep = 0
+	*pollCooldown = 60
+
+	endpoint := newTestEndpoint("x0c0s0b0")
+	var attempts int32
+
+	pollEndpointWithRetry(endpoint, river_collector.TelemetryTypePower, func() error {
+		atomic.AddInt32(&attempts, 1)
+		return errors.New("boom")
+	})
+
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Fatalf("expected exactly one attempt when retry-until-deadline is disabled, got %d", got)
+	}
+	if endpoint.CooldownUntil != nil {
+		t.Fatal("expected retry-until-deadline disabled (poll_retry_timeout=0) to never enter cooldown")
+	}
+	if endpointPollRetrying(endpoint.Endpoint.ID, river_collector.TelemetryTypePower) {
+		t.Fatal("expected the poll not to be left marked as in-flight")
+	}
+}
+
+func TestPollEndpointWithRetrySucceedsBeforeDeadline(t *testing.T) {
+	*pollRetryTimeout = 60
+	*pollRetrySleep = 0
+	*pollCooldown = 60
+
+	endpoint := newTestEndpoint("x0c0s0b1")
+	telemetryType := river_collector.TelemetryTypePower
+	var attempts int32
+
+	pollEndpointWithRetry(endpoint, telemetryType, func() error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return errors.New("transient failure")
+		}
+		return nil
+	})
+
+	waitForCondition(t, 2*time.Second, func() bool {
+		return atomic.LoadInt32(&attempts) >= 3 && !endpointPollRetrying(endpoint.Endpoint.ID, telemetryType)
+	})
+
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+	if endpoint.CooldownUntil != nil {
+		t.Fatal("expected a successful poll to never enter cooldown")
+	}
+}
+
+func TestPollEndpointWithRetryEntersCooldownAfterDeadline(t *testing.T) {
+	*pollRetryTimeout = 1
+	*pollRetrySleep = 0
+	*pollCooldown = 60
+
+	endpoint := newTestEndpoint("x0c0s0b2")
+	telemetryType := river_collector.TelemetryTypePower
+
+	pollEndpointWithRetry(endpoint, telemetryType, func() error {
+		return errors.New("persistent failure")
+	})
+
+	// CooldownUntil is set a few statements before the in-flight mark is
+	// cleared, so wait for the chain's terminal step to finish as a whole.
+	waitForCondition(t, 3*time.Second, func() bool {
+		return endpoint.CooldownUntil != nil && !endpointPollRetrying(endpoint.Endpoint.ID, telemetryType)
+	})
+
+	if endpointPollRetrying(endpoint.Endpoint.ID, telemetryType) {
+		t.Fatal("expected the poll not to be left marked as in-flight once cooldown is entered")
+	}
+}
+
+// TestPollEndpointWithRetryDoesNotBlockAWorker guards against the bug this
+// fix addresses: a retry's wait must be driven by a timer, not a
+// synchronous sleep inside the worker-pool job, and the endpoint must be
+// reported as in-flight for the whole pending window so doPolling doesn't
+// submit a duplicate job for it.
+func TestPollEndpointWithRetryDoesNotBlockAWorker(t *testing.T) {
+	*pollRetryTimeout = 60
+	*pollRetrySleep = 1
+	*pollCooldown = 60
+
+	endpoint := newTestEndpoint("x0c0s0b3")
+	telemetryType := river_collector.TelemetryTypePower
+
+	settled := make(chan struct{})
+	var attempts int32
+	pollEndpointWithRetry(endpoint, telemetryType, func() error {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return errors.New("first attempt fails")
+		}
+		close(settled)
+		return nil
+	})
+
+	// The first attempt fails synchronously and schedules a retry a second
+	// out; it must be reported in-flight for that whole window rather than
+	// having already resolved.
+	if !endpointPollRetrying(endpoint.Endpoint.ID, telemetryType) {
+		t.Fatal("expected the poll to be marked in-flight while its retry is pending")
+	}
+	select {
+	case <-settled:
+		t.Fatal("expected the retry to still be pending, not already resubmitted")
+	default:
+	}
+
+	waitForCondition(t, 3*time.Second, func() bool {
+		select {
+		case <-settled:
+			return true
+		default:
+			return false
+		}
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/hmcollector"
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/river_collector"
+)
+
+// doRest starts the REST server that serves Redfish event ingestion (when
+// enabled) and liveness/readiness probes (always). Like doAdmin, it starts
+// the listener on its own goroutine and returns immediately so the rest of
+// startup can continue; RestSRV.Shutdown is called from the signal handler
+// in main.
+func doRest() {
+	http.HandleFunc("/liveness", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	http.HandleFunc("/readiness", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Importing net/http/pprof (see doAdmin) registers its handlers on
+	// http.DefaultServeMux, which this server serves, so those paths are
+	// refused here and profiling is only reachable on the admin server.
+	restHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/debug/pprof") {
+			http.NotFound(w, r)
+			return
+		}
+		http.DefaultServeMux.ServeHTTP(w, r)
+	})
+
+	RestSRV = &http.Server{Addr: fmt.Sprintf(":%d", *restPort), Handler: restHandler}
+
+	go func() {
+		defer WaitGroup.Done()
+
+		logger.Info("Starting REST server.", zap.Int("restPort", *restPort))
+
+		if err := RestSRV.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("REST server failed.", zap.Error(err))
+		}
+	}()
+}
+
+// parseRequest handles an inbound Redfish event POST: it parses the body
+// for the telemetry type named in the "type" query parameter and forwards
+// the resulting events to Kafka.
+func parseRequest(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Unable to read REST request body.", zap.Error(err))
+		http.Error(w, "unable to read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	location := r.URL.Query().Get("location")
+	telemetryType := river_collector.TelemetryType(r.URL.Query().Get("type"))
+
+	var events []hmcollector.Event
+	switch telemetryType {
+	case river_collector.TelemetryTypePower:
+		events = intelCollector.ParseJSONPowerEvents(body, location)
+	case river_collector.TelemetryTypeThermal:
+		events = intelCollector.ParseJSONThermalEvents(body, location)
+	default:
+		logger.Warn("Rejecting REST request with unknown telemetry type.",
+			zap.String("type", string(telemetryType)))
+		http.Error(w, "unknown or missing telemetry type", http.StatusBadRequest)
+		return
+	}
+
+	for _, event := range events {
+		eventBytes, marshalErr := json.Marshal(event)
+		if marshalErr != nil {
+			logger.Error("Unable to marshal event for Kafka.", zap.Error(marshalErr))
+			continue
+		}
+
+		produceToKafka("telemetry", eventBytes)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
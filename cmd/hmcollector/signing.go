@@ -0,0 +1,141 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/namsral/flag"
+	"go.uber.org/zap"
+
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/hmcollector"
+)
+
+const (
+	signatureTimestampHeader = "X-HMCOLLECTOR-TIMESTAMP"
+	signatureHeader          = "X-HMCOLLECTOR-SIGN"
+
+	// seenSignatureCacheSize bounds the replay-protection LRU; it only needs
+	// to cover bursts within the signature window, not the lifetime of the
+	// process.
+	seenSignatureCacheSize = 8192
+)
+
+var (
+	restRequireSignature = flag.Bool("rest_require_signature", false,
+		"Should incoming REST requests be required to carry a valid X-HMCOLLECTOR-SIGN HMAC signature?")
+	restSignatureWindow = flag.Int("rest_signature_window", 300,
+		"Maximum age, in seconds, of the X-HMCOLLECTOR-TIMESTAMP header before a signed request is rejected as a replay.")
+	restSignatureVaultKeypath = flag.String("rest_signature_vault_keypath", "secret/hms-creds/hmcollector-signing",
+		"Vault keypath holding the HMAC signing secret for signed REST ingestion.")
+
+	restSignatureSecret []byte
+
+	seenSignatures *lru.Cache
+)
+
+// loadRestSignatureSecret pulls the shared HMAC signing secret out of Vault
+// using the same credential plumbing the BMC endpoint lookups already rely
+// on (see updateEndpointWithCredentials). It's a no-op unless signature
+// enforcement is turned on.
+func loadRestSignatureSecret() error {
+	if !*restRequireSignature {
+		return nil
+	}
+
+	secret, err := hmcollector.GetVaultSigningSecret(*restSignatureVaultKeypath)
+	if err != nil {
+		return fmt.Errorf("unable to load REST signing secret from Vault: %v", err)
+	}
+
+	restSignatureSecret = secret
+
+	cache, err := lru.New(seenSignatureCacheSize)
+	if err != nil {
+		return fmt.Errorf("unable to create replay-protection cache: %v", err)
+	}
+	seenSignatures = cache
+
+	return nil
+}
+
+// requireValidSignature wraps a handler so that, when signature enforcement
+// is enabled, every request must carry a valid HMAC-SHA256 over
+// timestamp||body, a timestamp inside the configured window, and a
+// (sender, timestamp, signature) tuple not already seen. Unsigned-mode
+// deployments fall straight through to next.
+func requireValidSignature(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !*restRequireSignature {
+			next(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			logger.Warn("Unable to read body of signed request.", zap.Error(err))
+			http.Error(w, "unable to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+
+		if err := verifyRequestSignature(r.RemoteAddr, r.Header.Get(signatureTimestampHeader),
+			r.Header.Get(signatureHeader), body); err != nil {
+			logger.Warn("Rejecting REST request with invalid signature.",
+				zap.String("remoteAddr", r.RemoteAddr), zap.Error(err))
+			http.Error(w, "invalid or missing signature", http.StatusUnauthorized)
+			return
+		}
+
+		// Give the wrapped handler an equivalent, re-readable body.
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next(w, r)
+	}
+}
+
+// verifyRequestSignature recomputes HMAC-SHA256(secret, timestamp||body) and
+// compares it against the caller-supplied signature in constant time,
+// rejects timestamps outside restSignatureWindow, and rejects
+// (sender, timestamp, signature) tuples already seen to stop replays.
+func verifyRequestSignature(sender, timestampHeader, signature string, body []byte) error {
+	if timestampHeader == "" || signature == "" {
+		return fmt.Errorf("missing %s or %s header", signatureTimestampHeader, signatureHeader)
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed %s header: %v", signatureTimestampHeader, err)
+	}
+
+	age := time.Since(time.Unix(timestamp, 0))
+	if math.Abs(age.Seconds()) > float64(*restSignatureWindow) {
+		return fmt.Errorf("timestamp outside of the %d second signature window", *restSignatureWindow)
+	}
+
+	mac := hmac.New(sha256.New, restSignatureSecret)
+	mac.Write([]byte(timestampHeader))
+	mac.Write(body)
+	expectedSignature := fmt.Sprintf("%x", mac.Sum(nil))
+
+	if subtle.ConstantTimeCompare([]byte(expectedSignature), []byte(signature)) != 1 {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	replayKey := fmt.Sprintf("%s|%s|%s", sender, timestampHeader, signature)
+	if _, seen := seenSignatures.Get(replayKey); seen {
+		return fmt.Errorf("replayed (sender, timestamp, signature) tuple")
+	}
+	seenSignatures.Add(replayKey, true)
+
+	return nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func setupSignatureTest(t *testing.T) {
+	t.Helper()
+
+	restSignatureSecret = []byte("test-secret")
+
+	cache, err := lru.New(seenSignatureCacheSize)
+	if err != nil {
+		t.Fatalf("unable to create LRU cache: %v", err)
+	}
+	seenSignatures = cache
+}
+
+func signBody(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+func TestVerifyRequestSignatureAcceptsValidSignature(t *testing.T) {
+	setupSignatureTest(t)
+
+	body := []byte(`{"hello":"world"}`)
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	signature := signBody(restSignatureSecret, timestamp, body)
+
+	if err := verifyRequestSignature("10.0.0.1", timestamp, signature, body); err != nil {
+		t.Fatalf("expected valid signature to be accepted, got error: %v", err)
+	}
+}
+
+func TestVerifyRequestSignatureRejectsBadSignature(t *testing.T) {
+	setupSignatureTest(t)
+
+	body := []byte(`{"hello":"world"}`)
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+
+	if err := verifyRequestSignature("10.0.0.1", timestamp, "deadbeef", body); err == nil {
+		t.Fatal("expected mismatched signature to be rejected")
+	}
+}
+
+func TestVerifyRequestSignatureRejectsStaleTimestamp(t *testing.T) {
+	setupSignatureTest(t)
+
+	body := []byte(`{"hello":"world"}`)
+	staleTimestamp := fmt.Sprintf("%d", time.Now().Add(-time.Hour).Unix())
+	signature := signBody(restSignatureSecret, staleTimestamp, body)
+
+	if err := verifyRequestSignature("10.0.0.1", staleTimestamp, signature, body); err == nil {
+		t.Fatal("expected a timestamp outside the signature window to be rejected")
+	}
+}
+
+func TestVerifyRequestSignatureRejectsReplay(t *testing.T) {
+	setupSignatureTest(t)
+
+	body := []byte(`{"hello":"world"}`)
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	signature := signBody(restSignatureSecret, timestamp, body)
+
+	if err := verifyRequestSignature("10.0.0.1", timestamp, signature, body); err != nil {
+		t.Fatalf("expected first use of signature to be accepted, got error: %v", err)
+	}
+
+	if err := verifyRequestSignature("10.0.0.1", timestamp, signature, body); err == nil {
+		t.Fatal("expected a replayed (sender, timestamp, signature) tuple to be rejected")
+	}
+}
+
+func TestVerifyRequestSignatureRejectsMissingHeaders(t *testing.T) {
+	setupSignatureTest(t)
+
+	body := []byte(`{"hello":"world"}`)
+
+	if err := verifyRequestSignature("10.0.0.1", "", "", body); err == nil {
+		t.Fatal("expected missing timestamp/signature headers to be rejected")
+	}
+}
+
+func TestLoadRestSignatureSecretNoopWhenDisabled(t *testing.T) {
+	disabled := false
+	restRequireSignature = &disabled
+
+	restSignatureSecret = nil
+	seenSignatures = nil
+
+	if err := loadRestSignatureSecret(); err != nil {
+		t.Fatalf("expected loadRestSignatureSecret to be a no-op when disabled, got error: %v", err)
+	}
+
+	if restSignatureSecret != nil || seenSignatures != nil {
+		t.Fatal("expected loadRestSignatureSecret to leave signing state untouched when disabled")
+	}
+}
+
+func TestRequireValidSignaturePassesThroughWhenDisabled(t *testing.T) {
+	disabled := false
+	restRequireSignature = &disabled
+
+	called := false
+	handler := requireValidSignature(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"hello":"world"}`))
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if !called {
+		t.Fatal("expected the wrapped handler to run when signature enforcement is disabled")
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestRequireValidSignatureEndToEnd(t *testing.T) {
+	enabled := true
+	restRequireSignature = &enabled
+	defer func() {
+		disabled := false
+		restRequireSignature = &disabled
+	}()
+
+	setupSignatureTest(t)
+
+	var gotBody []byte
+	handler := requireValidSignature(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	body := []byte(`{"hello":"world"}`)
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	signature := signBody(restSignatureSecret, timestamp, body)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Set(signatureTimestampHeader, timestamp)
+	req.Header.Set(signatureHeader, signature)
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a validly signed request to pass through, got %d", rec.Code)
+	}
+	if string(gotBody) != string(body) {
+		t.Fatalf("expected the wrapped handler to see the original body, got %q", gotBody)
+	}
+
+	// A second run through with the same headers is a replay and must be
+	// rejected even though the signature itself is still valid.
+	req2 := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req2.Header.Set(signatureTimestampHeader, timestamp)
+	req2.Header.Set(signatureHeader, signature)
+	rec2 := httptest.NewRecorder()
+
+	handler(rec2, req2)
+
+	if rec2.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a replayed request to be rejected with 401, got %d", rec2.Code)
+	}
+}
+
+func TestRequireValidSignatureRejectsBadSignature(t *testing.T) {
+	enabled := true
+	restRequireSignature = &enabled
+	defer func() {
+		disabled := false
+		restRequireSignature = &disabled
+	}()
+
+	setupSignatureTest(t)
+
+	handler := requireValidSignature(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("expected the wrapped handler not to run for an invalid signature")
+	})
+
+	body := []byte(`{"hello":"world"}`)
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Set(signatureTimestampHeader, timestamp)
+	req.Header.Set(signatureHeader, "deadbeef")
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an invalid signature, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package hmcollector
+
+import "github.com/confluentinc/confluent-kafka-go/kafka"
+
+// KafkaBroker describes one Kafka broker this collector produces to, as
+// read out of kafka_brokers.json.
+type KafkaBroker struct {
+	Name  string   `json:"name"`
+	Peers []string `json:"peers"`
+
+	// Security configures TLS/SASL for this broker. A nil or zero-value
+	// Security block (the historical default) produces a plaintext
+	// ConfigMap, same as before Security existed.
+	Security *KafkaBrokerSecurity `json:"security,omitempty"`
+
+	KafkaProducer *kafka.Producer `json:"-"`
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package hmcollector
+
+import (
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"stash.us.cray.com/HMS/hms-certs/pkg/hms_certs"
+)
+
+// SecurityProtocol mirrors librdkafka's security.protocol values.
+type SecurityProtocol string
+
+const (
+	SecurityProtocolPlaintext     SecurityProtocol = "PLAINTEXT"
+	SecurityProtocolSSL           SecurityProtocol = "SSL"
+	SecurityProtocolSASLSSL       SecurityProtocol = "SASL_SSL"
+	SecurityProtocolSASLPlaintext SecurityProtocol = "SASL_PLAINTEXT"
+)
+
+// SASLMechanism mirrors librdkafka's sasl.mechanism values.
+type SASLMechanism string
+
+const (
+	SASLMechanismPlain       SASLMechanism = "PLAIN"
+	SASLMechanismScramSHA256 SASLMechanism = "SCRAM-SHA-256"
+	SASLMechanismScramSHA512 SASLMechanism = "SCRAM-SHA-512"
+)
+
+// KafkaBrokerSecurity is the per-broker security block read out of
+// kafka_brokers.json, held in KafkaBroker's `Security` field. When
+// VaultKeypath is set it is resolved when the producers are built and
+// replaces CAFile/CertFile/KeyFile; otherwise those paths are used as-is.
+type KafkaBrokerSecurity struct {
+	SecurityProtocol SecurityProtocol `json:"security_protocol"`
+	SASLMechanism    SASLMechanism    `json:"sasl_mechanism,omitempty"`
+	SASLUsername     string           `json:"sasl_username,omitempty"`
+	SASLPassword     string           `json:"sasl_password,omitempty"`
+
+	CAFile   string `json:"ca_file,omitempty"`
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// VaultKeypath, when set, takes precedence over CAFile/CertFile/KeyFile:
+	// the CA/cert/key material is pulled from Vault at startup and whenever
+	// the trust bundle rolls, reusing the hms_certs plumbing already used
+	// for Redfish client material.
+	VaultKeypath string `json:"vault_keypath,omitempty"`
+}
+
+// ResolveFromVault populates CAFile/CertFile/KeyFile from Vault when
+// VaultKeypath is set. It is a no-op for brokers configured with explicit
+// file paths or with no security block at all.
+func (security *KafkaBrokerSecurity) ResolveFromVault() error {
+	if security == nil || security.VaultKeypath == "" {
+		return nil
+	}
+
+	caFile, certFile, keyFile, err := hms_certs.FetchKeypairFromVault(security.VaultKeypath)
+	if err != nil {
+		return fmt.Errorf("unable to resolve Kafka broker security material from Vault keypath %s: %v",
+			security.VaultKeypath, err)
+	}
+
+	security.CAFile = caFile
+	security.CertFile = certFile
+	security.KeyFile = keyFile
+
+	return nil
+}
+
+// ApplyToConfigMap wires this security block into the ConfigMap that's
+// handed to kafka.NewProducer, translating the declarative JSON fields into
+// the librdkafka properties it expects. Brokers with no security block (the
+// historical plaintext default) are left untouched.
+func (security *KafkaBrokerSecurity) ApplyToConfigMap(configMap *kafka.ConfigMap) error {
+	if security == nil || security.SecurityProtocol == "" || security.SecurityProtocol == SecurityProtocolPlaintext {
+		return nil
+	}
+
+	if err := configMap.SetKey("security.protocol", string(security.SecurityProtocol)); err != nil {
+		return err
+	}
+
+	switch security.SecurityProtocol {
+	case SecurityProtocolSSL, SecurityProtocolSASLSSL:
+		if security.CAFile != "" {
+			if err := configMap.SetKey("ssl.ca.location", security.CAFile); err != nil {
+				return err
+			}
+		}
+		if security.CertFile != "" {
+			if err := configMap.SetKey("ssl.certificate.location", security.CertFile); err != nil {
+				return err
+			}
+		}
+		if security.KeyFile != "" {
+			if err := configMap.SetKey("ssl.key.location", security.KeyFile); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch security.SecurityProtocol {
+	case SecurityProtocolSASLSSL, SecurityProtocolSASLPlaintext:
+		if security.SASLMechanism == "" {
+			return fmt.Errorf("sasl_mechanism is required for security_protocol %s", security.SecurityProtocol)
+		}
+		if err := configMap.SetKey("sasl.mechanism", string(security.SASLMechanism)); err != nil {
+			return err
+		}
+		if err := configMap.SetKey("sasl.username", security.SASLUsername); err != nil {
+			return err
+		}
+		if err := configMap.SetKey("sasl.password", security.SASLPassword); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package hmcollector
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestApplyToConfigMapPlaintextIsNoOp(t *testing.T) {
+	configMap := &kafka.ConfigMap{}
+
+	if err := (&KafkaBrokerSecurity{}).ApplyToConfigMap(configMap); err != nil {
+		t.Fatalf("unexpected error for empty security block: %v", err)
+	}
+	if len(*configMap) != 0 {
+		t.Fatalf("expected no keys set for a plaintext broker, got %v", configMap)
+	}
+
+	explicit := &KafkaBrokerSecurity{SecurityProtocol: SecurityProtocolPlaintext}
+	if err := explicit.ApplyToConfigMap(configMap); err != nil {
+		t.Fatalf("unexpected error for explicit PLAINTEXT: %v", err)
+	}
+	if len(*configMap) != 0 {
+		t.Fatalf("expected no keys set for explicit PLAINTEXT, got %v", configMap)
+	}
+}
+
+func TestApplyToConfigMapSSL(t *testing.T) {
+	configMap := &kafka.ConfigMap{}
+	security := &KafkaBrokerSecurity{
+		SecurityProtocol: SecurityProtocolSSL,
+		CAFile:           "/etc/hmcollector/ca.pem",
+		CertFile:         "/etc/hmcollector/cert.pem",
+		KeyFile:          "/etc/hmcollector/key.pem",
+	}
+
+	if err := security.ApplyToConfigMap(configMap); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"security.protocol":        "SSL",
+		"ssl.ca.location":          security.CAFile,
+		"ssl.certificate.location": security.CertFile,
+		"ssl.key.location":         security.KeyFile,
+	} {
+		got, ok := (*configMap)[key]
+		if !ok {
+			t.Fatalf("expected key %q to be set", key)
+		}
+		if got != kafka.ConfigValue(want) {
+			t.Fatalf("key %q: got %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestApplyToConfigMapSASLSSLRequiresMechanism(t *testing.T) {
+	configMap := &kafka.ConfigMap{}
+	security := &KafkaBrokerSecurity{
+		SecurityProtocol: SecurityProtocolSASLSSL,
+		SASLUsername:     "collector",
+		SASLPassword:     "hunter2",
+	}
+
+	if err := security.ApplyToConfigMap(configMap); err == nil {
+		t.Fatal("expected an error when sasl_mechanism is missing for a SASL protocol")
+	}
+}
+
+func TestApplyToConfigMapSASLSSL(t *testing.T) {
+	configMap := &kafka.ConfigMap{}
+	security := &KafkaBrokerSecurity{
+		SecurityProtocol: SecurityProtocolSASLSSL,
+		SASLMechanism:    SASLMechanismScramSHA512,
+		SASLUsername:     "collector",
+		SASLPassword:     "hunter2",
+		CAFile:           "/etc/hmcollector/ca.pem",
+	}
+
+	if err := security.ApplyToConfigMap(configMap); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"security.protocol": "SASL_SSL",
+		"sasl.mechanism":    "SCRAM-SHA-512",
+		"sasl.username":     "collector",
+		"sasl.password":     "hunter2",
+		"ssl.ca.location":   security.CAFile,
+	} {
+		got, ok := (*configMap)[key]
+		if !ok {
+			t.Fatalf("expected key %q to be set", key)
+		}
+		if got != kafka.ConfigValue(want) {
+			t.Fatalf("key %q: got %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestApplyToConfigMapNilSecurityIsNoOp(t *testing.T) {
+	configMap := &kafka.ConfigMap{}
+
+	var security *KafkaBrokerSecurity
+	if err := security.ApplyToConfigMap(configMap); err != nil {
+		t.Fatalf("unexpected error for nil security block: %v", err)
+	}
+	if len(*configMap) != 0 {
+		t.Fatalf("expected no keys set for a nil security block, got %v", configMap)
+	}
+}
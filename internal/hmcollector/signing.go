@@ -0,0 +1,22 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package hmcollector
+
+import (
+	"fmt"
+
+	"stash.us.cray.com/HMS/hms-certs/pkg/hms_certs"
+)
+
+// GetVaultSigningSecret fetches the shared HMAC signing secret used to
+// authenticate signed REST event ingestion out of Vault, reusing the same
+// hms_certs Vault plumbing the BMC credential and Kafka broker security
+// lookups already rely on.
+func GetVaultSigningSecret(keypath string) ([]byte, error) {
+	secret, err := hms_certs.FetchSecretFromVault(keypath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch signing secret from Vault keypath %s: %v", keypath, err)
+	}
+
+	return []byte(secret), nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+// Package metrics holds the Prometheus collectors shared across the
+// collector's packages (cmd/hmcollector, internal/river_collector, ...) so
+// that instrumentation can live next to the code it measures without
+// introducing import cycles back into package main.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// EventsReceivedTotal counts every Event produced by a telemetry parser,
+	// broken down by the Redfish MessageId and the originating TelemetrySource.
+	EventsReceivedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hmcollector_events_received_total",
+		Help: "Total number of telemetry events parsed, by message ID and telemetry source.",
+	}, []string{"message_id", "telemetry_source"})
+
+	// RedfishPollLatencySeconds tracks how long it takes to poll a Redfish
+	// endpoint for a given telemetry type.
+	RedfishPollLatencySeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hmcollector_redfish_poll_latency_seconds",
+		Help:    "Latency of Redfish telemetry polls, by endpoint and telemetry type.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "telemetry_type"})
+
+	// HSMEndpointsGauge reports the number of Redfish endpoints the collector
+	// currently knows about.
+	HSMEndpointsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "hmcollector_hsm_endpoints",
+		Help: "Number of Redfish endpoints currently tracked from the State Manager.",
+	})
+
+	// KafkaProduceTotal counts Kafka produce outcomes, by broker, topic, and
+	// result ("success" or "failure").
+	KafkaProduceTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hmcollector_kafka_produce_total",
+		Help: "Total number of Kafka produce attempts, by broker, topic, and result.",
+	}, []string{"broker", "topic", "result"})
+
+	// CollectorPanicsTotal counts panics recovered from worker pool jobs, by
+	// the stage that was running (e.g. "polling", "rf_subscribe").
+	CollectorPanicsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hmcollector_panics_total",
+		Help: "Total number of panics recovered from worker pool jobs, by stage.",
+	}, []string{"stage"})
+
+	// WorkerPoolQueueDepth reports how many jobs are currently buffered
+	// waiting for a free worker.
+	WorkerPoolQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "hmcollector_worker_pool_queue_depth",
+		Help: "Number of jobs buffered in the worker pool's job queue.",
+	})
+
+	// WorkerPoolInFlight reports how many jobs are currently being executed
+	// by a worker.
+	WorkerPoolInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "hmcollector_worker_pool_in_flight",
+		Help: "Number of worker pool jobs currently executing.",
+	})
+
+	// PollAttemptsTotal counts individual poll attempts, by endpoint,
+	// telemetry type, and result ("success" or "failure").
+	PollAttemptsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hmcollector_poll_attempts_total",
+		Help: "Total number of Redfish poll attempts, by endpoint, telemetry type, and result.",
+	}, []string{"endpoint", "telemetry_type", "result"})
+
+	// PollCooldownTotal counts how many times an endpoint was marked
+	// unhealthy and put into cooldown after exhausting its retry deadline.
+	PollCooldownTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hmcollector_poll_cooldown_total",
+		Help: "Total number of times an endpoint was put into cooldown after exhausting poll retries.",
+	}, []string{"endpoint"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		EventsReceivedTotal,
+		RedfishPollLatencySeconds,
+		HSMEndpointsGauge,
+		KafkaProduceTotal,
+		CollectorPanicsTotal,
+		WorkerPoolQueueDepth,
+		WorkerPoolInFlight,
+		PollAttemptsTotal,
+		PollCooldownTotal,
+	)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"stash.us.cray.com/HMS/hms-hmcollector/internal/hmcollector"
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/metrics"
 	rf "stash.us.cray.com/HMS/hms-smd/pkg/redfish"
 	"strconv"
 	"time"
@@ -47,6 +48,7 @@ func (collector IntelRiverCollector) ParseJSONPowerEvents(payloadBytes []byte,
 	}
 
 	events = append(events, powerEvent)
+	metrics.EventsReceivedTotal.WithLabelValues(powerEvent.MessageId, powerEvent.Oem.TelemetrySource).Inc()
 
 	voltageEvent := hmcollector.Event{
 		MessageId:      VoltageMessageID,
@@ -87,6 +89,7 @@ func (collector IntelRiverCollector) ParseJSONPowerEvents(payloadBytes []byte,
 	}
 
 	events = append(events, voltageEvent)
+	metrics.EventsReceivedTotal.WithLabelValues(voltageEvent.MessageId, voltageEvent.Oem.TelemetrySource).Inc()
 
 	return
 }
@@ -124,6 +127,7 @@ func (collector IntelRiverCollector) ParseJSONThermalEvents(payloadBytes []byte,
 	}
 
 	events = append(events, temperatureEvent)
+	metrics.EventsReceivedTotal.WithLabelValues(temperatureEvent.MessageId, temperatureEvent.Oem.TelemetrySource).Inc()
 
 	return
 }
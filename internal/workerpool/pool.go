@@ -0,0 +1,107 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+// Package workerpool provides a fixed-size pool of goroutines fed by a
+// buffered job channel. It exists so that fan-out work such as Redfish
+// polling and subscription processing can't spawn an unbounded goroutine
+// per endpoint, and so that a panic in one job (e.g. a malformed Redfish
+// payload) can't take the whole process down with it.
+package workerpool
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"stash.us.cray.com/HMS/hms-hmcollector/internal/metrics"
+)
+
+// Job is a unit of work submitted to a Pool. stage identifies the caller for
+// logging and for the collector_panics_total metric (e.g. "polling",
+// "rf_subscribe").
+type Job struct {
+	Stage string
+	Run   func()
+}
+
+// Pool is a fixed-size set of workers draining a buffered job queue.
+type Pool struct {
+	jobs   chan Job
+	wg     sync.WaitGroup
+	logger *zap.Logger
+}
+
+// New creates a Pool with a job queue buffered to queueSize. New does not
+// use numWorkers; the number of workers is the count passed to Start.
+func New(logger *zap.Logger, numWorkers, queueSize int) *Pool {
+	return &Pool{
+		jobs:   make(chan Job, queueSize),
+		logger: logger,
+	}
+}
+
+// Start launches the pool's workers. Workers run until Stop is called; the
+// pool intentionally has no other lifecycle hook so that cancelling an
+// unrelated context (e.g. the one used to cancel in-flight HTTP requests on
+// shutdown) can never stop workers out from under jobs that are still being
+// submitted.
+func (pool *Pool) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		pool.wg.Add(1)
+		go pool.work()
+	}
+}
+
+// Submit enqueues a job for execution. It blocks if the queue is full,
+// applying natural backpressure to callers instead of spawning more
+// goroutines than the pool can run.
+func (pool *Pool) Submit(job Job) {
+	metrics.WorkerPoolQueueDepth.Inc()
+	pool.jobs <- job
+}
+
+// Stop closes the job queue and waits for all workers to drain it or for
+// ctx to be cancelled, whichever comes first, so shutdown never blocks on a
+// stuck job.
+func (pool *Pool) Stop(ctx context.Context) {
+	close(pool.jobs)
+
+	done := make(chan struct{})
+	go func() {
+		pool.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		pool.logger.Warn("Worker pool shutdown context cancelled before all workers drained.")
+	}
+}
+
+func (pool *Pool) work() {
+	defer pool.wg.Done()
+
+	for job := range pool.jobs {
+		metrics.WorkerPoolQueueDepth.Dec()
+		pool.run(job)
+	}
+}
+
+func (pool *Pool) run(job Job) {
+	metrics.WorkerPoolInFlight.Inc()
+	defer metrics.WorkerPoolInFlight.Dec()
+
+	defer func() {
+		if r := recover(); r != nil {
+			pool.logger.Error("Recovered from panic in worker pool job.",
+				zap.String("stage", job.Stage),
+				zap.Any("panic", r),
+				zap.StackSkip("stack", 1))
+
+			metrics.CollectorPanicsTotal.WithLabelValues(job.Stage).Inc()
+		}
+	}()
+
+	job.Run()
+}
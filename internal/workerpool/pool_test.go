@@ -0,0 +1,107 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package workerpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPoolRecoversPanicAndKeepsRunning(t *testing.T) {
+	pool := New(zap.NewNop(), 1, 4)
+	pool.Start(1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	pool.Submit(Job{
+		Stage: "test",
+		Run: func() {
+			defer wg.Done()
+			panic("boom")
+		},
+	})
+	wg.Wait()
+
+	var ran bool
+	var doneWg sync.WaitGroup
+	doneWg.Add(1)
+	pool.Submit(Job{
+		Stage: "test",
+		Run: func() {
+			defer doneWg.Done()
+			ran = true
+		},
+	})
+	doneWg.Wait()
+
+	if !ran {
+		t.Fatal("expected a job submitted after a panicking job to still run")
+	}
+}
+
+func TestPoolStopDrainsQueuedJobs(t *testing.T) {
+	pool := New(zap.NewNop(), 2, 8)
+	pool.Start(2)
+
+	var count int32
+	var mu sync.Mutex
+	const numJobs = 5
+
+	for i := 0; i < numJobs; i++ {
+		pool.Submit(Job{
+			Stage: "test",
+			Run: func() {
+				mu.Lock()
+				count++
+				mu.Unlock()
+			},
+		})
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	pool.Stop(stopCtx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if count != numJobs {
+		t.Fatalf("expected all %d jobs to run before Stop returned, got %d", numJobs, count)
+	}
+}
+
+// TestPoolSurvivesUnrelatedContextCancellation guards against the pool
+// sharing its lifecycle with a caller-owned context (e.g. the one used to
+// cancel in-flight HTTP requests on shutdown): cancelling such a context
+// must never stop workers out from under jobs still being submitted.
+func TestPoolSurvivesUnrelatedContextCancellation(t *testing.T) {
+	pool := New(zap.NewNop(), 1, 4)
+	pool.Start(1)
+
+	unrelatedCtx, cancelUnrelated := context.WithCancel(context.Background())
+	cancelUnrelated()
+	<-unrelatedCtx.Done()
+
+	var ran bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	pool.Submit(Job{
+		Stage: "test",
+		Run: func() {
+			defer wg.Done()
+			ran = true
+		},
+	})
+	wg.Wait()
+
+	if !ran {
+		t.Fatal("expected a job submitted after an unrelated context was cancelled to still run")
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	pool.Stop(stopCtx)
+}